@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// benchmarkMetrics accumulates per-chunk latencies observed by uploadChunks
+// and downloadChunks while cfg.metrics is set, so the benchmark subcommand
+// can report percentiles without those functions knowing they're being
+// benchmarked.
+type benchmarkMetrics struct {
+	mu        sync.Mutex
+	uploads   []time.Duration
+	downloads []time.Duration
+}
+
+func (m *benchmarkMetrics) recordUpload(d time.Duration) {
+	m.mu.Lock()
+	m.uploads = append(m.uploads, d)
+	m.mu.Unlock()
+}
+
+func (m *benchmarkMetrics) recordDownload(d time.Duration) {
+	m.mu.Lock()
+	m.downloads = append(m.downloads, d)
+	m.mu.Unlock()
+}
+
+type latencyStats struct {
+	Min    time.Duration
+	Median time.Duration
+	P95    time.Duration
+	Max    time.Duration
+}
+
+func summarizeLatencies(durations []time.Duration) latencyStats {
+	if len(durations) == 0 {
+		return latencyStats{}
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	percentile := func(p float64) time.Duration {
+		idx := int(p * float64(len(sorted)-1))
+		return sorted[idx]
+	}
+	return latencyStats{
+		Min:    sorted[0],
+		Median: percentile(0.5),
+		P95:    percentile(0.95),
+		Max:    sorted[len(sorted)-1],
+	}
+}
+
+// latencyStatsJSON mirrors latencyStats in millisecond floats for
+// machine-readable output.
+type latencyStatsJSON struct {
+	MinMS    float64 `json:"min_ms"`
+	MedianMS float64 `json:"median_ms"`
+	P95MS    float64 `json:"p95_ms"`
+	MaxMS    float64 `json:"max_ms"`
+}
+
+func (s latencyStats) toJSON() latencyStatsJSON {
+	return latencyStatsJSON{
+		MinMS:    s.Min.Seconds() * 1000,
+		MedianMS: s.Median.Seconds() * 1000,
+		P95MS:    s.P95.Seconds() * 1000,
+		MaxMS:    s.Max.Seconds() * 1000,
+	}
+}
+
+type benchmarkResult struct {
+	TotalBytes       int64            `json:"total_bytes"`
+	ChunkCount       int              `json:"chunk_count"`
+	UploadSeconds    float64          `json:"upload_seconds"`
+	UploadMBPerSec   float64          `json:"upload_mb_per_sec"`
+	DownloadSeconds  float64          `json:"download_seconds"`
+	DownloadMBPerSec float64          `json:"download_mb_per_sec"`
+	UploadLatency    latencyStatsJSON `json:"upload_latency"`
+	DownloadLatency  latencyStatsJSON `json:"download_latency"`
+	RoundTripOK      bool             `json:"round_trip_ok"`
+	SourceSHA256     string           `json:"source_sha256"`
+	MergedSHA256     string           `json:"merged_sha256"`
+}
+
+// writeRandomSourceFile creates a random-filled (not sparse) file of size
+// bytes, so the benchmark measures real read/write throughput
+// instead of the near-instant I/O a hole-punched file would give.
+func writeRandomSourceFile(path string, size int64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.CopyN(f, rand.Reader, size); err != nil {
+		return fmt.Errorf("writing random source file: %w", err)
+	}
+	return nil
+}
+
+// runBenchmark implements the `benchmark` subcommand: it drives the same
+// splitFile/uploadChunks/downloadChunks/mergeChunks code paths as the normal
+// workflow (so concurrency and retry behavior are reflected here too), and
+// reports throughput and latency instead of just logging. It always runs
+// with cfg.resume = false and never calls buildManifest/writeManifest, since
+// a benchmark run has no manifest.json for a later run to resume against.
+func runBenchmark(args []string) error {
+	fs := flag.NewFlagSet("benchmark", flag.ExitOnError)
+	size := fs.String("size", "4GB", "expected total size; the random source file is always -chunk-count * -chunk-size bytes, and a mismatch with this is only logged")
+	chunkSizeFlag := fs.String("chunk-size", "256MB", "size for each fragment")
+	chunkCount := fs.Int("chunk-count", 16, "number of fragments to create")
+	uploadThreads := fs.Int("upload-threads", runtime.NumCPU(), "concurrent upload workers")
+	downloadThreads := fs.Int("download-threads", runtime.NumCPU(), "concurrent download workers")
+	clientBin := fs.String("client-bin", "0g-storage-client", "path to 0g-storage-client executable")
+	remotePrefix := fs.String("remote-prefix", "benchmark", "prefix used when naming remote fragments")
+	fragmentSize := fs.String("fragment-size", "256MB", "fragment size passed to 0g-storage-client")
+	workDir := fs.String("work-dir", filepath.Join(os.TempDir(), "0g-benchmark"), "base working directory")
+	maxRetries := fs.Int("max-retries", 3, "maximum retries for a failing chunk operation")
+	retryBase := fs.Duration("retry-base", 500*time.Millisecond, "base backoff duration between retries (doubles each attempt)")
+	jsonOutput := fs.Bool("json", false, "emit machine-readable JSON instead of log lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	chunkSizeBytes, err := parseSize(*chunkSizeFlag)
+	if err != nil {
+		return fmt.Errorf("parsing -chunk-size: %w", err)
+	}
+	totalSize, err := parseSize(*size)
+	if err != nil {
+		return fmt.Errorf("parsing -size: %w", err)
+	}
+	if want := int64(*chunkCount) * chunkSizeBytes; want != totalSize {
+		log.Printf("warning: -chunk-count * -chunk-size = %d bytes, which does not equal -size = %d bytes; using -chunk-count and -chunk-size", want, totalSize)
+	}
+
+	work := filepath.Clean(*workDir)
+	cfg := config{
+		sourceFile:   filepath.Join(work, "source.bin"),
+		workDir:      work,
+		chunkDir:     filepath.Join(work, "chunks"),
+		downloadDir:  filepath.Join(work, "downloads"),
+		mergedFile:   filepath.Join(work, "merged.bin"),
+		chunkSize:    chunkSizeBytes,
+		chunkCount:   *chunkCount,
+		chunker:      "fixed",
+		clientBin:    *clientBin,
+		remotePrefix: *remotePrefix,
+		fragmentSize: *fragmentSize,
+		maxRetries:   *maxRetries,
+		retryBase:    *retryBase,
+		resume:       false,
+		metrics:      &benchmarkMetrics{},
+	}
+	totalBytes := int64(cfg.chunkCount) * cfg.chunkSize
+
+	for _, dir := range []string{cfg.workDir, cfg.chunkDir, cfg.downloadDir, cacheDir(cfg)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating work dir %s: %w", dir, err)
+		}
+	}
+
+	if err := ensureBinary(cfg.clientBin); err != nil {
+		return err
+	}
+
+	log.Printf("benchmark: generating %d-byte random source file at %s", totalBytes, cfg.sourceFile)
+	if err := writeRandomSourceFile(cfg.sourceFile, totalBytes); err != nil {
+		return err
+	}
+
+	chunks, err := splitFile(cfg, cfg.sourceFile, cfg.chunkDir, cfg.chunkSize, cfg.chunkCount)
+	if err != nil {
+		return fmt.Errorf("splitting file: %w", err)
+	}
+	meta := makeChunkMeta(cfg.remotePrefix, chunks)
+
+	srcHash, err := fileHash(cfg.sourceFile)
+	if err != nil {
+		return fmt.Errorf("hashing source file: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Hour)
+	defer cancel()
+
+	cfg.concurrency = *uploadThreads
+	uploadStart := time.Now()
+	if err := uploadChunks(ctx, cfg, meta); err != nil {
+		return fmt.Errorf("benchmark upload: %w", err)
+	}
+	uploadElapsed := time.Since(uploadStart)
+
+	cfg.concurrency = *downloadThreads
+	downloadStart := time.Now()
+	downloaded, err := downloadChunks(ctx, cfg, meta)
+	if err != nil {
+		return fmt.Errorf("benchmark download: %w", err)
+	}
+	downloadElapsed := time.Since(downloadStart)
+
+	merged, err := mergeChunks(downloaded, cfg.mergedFile, false)
+	if err != nil {
+		return fmt.Errorf("merging downloaded fragments: %w", err)
+	}
+	dstHash, err := fileHash(merged)
+	if err != nil {
+		return fmt.Errorf("hashing merged file: %w", err)
+	}
+
+	result := benchmarkResult{
+		TotalBytes:       totalBytes,
+		ChunkCount:       cfg.chunkCount,
+		UploadSeconds:    uploadElapsed.Seconds(),
+		UploadMBPerSec:   bytesPerSec(totalBytes, uploadElapsed),
+		DownloadSeconds:  downloadElapsed.Seconds(),
+		DownloadMBPerSec: bytesPerSec(totalBytes, downloadElapsed),
+		UploadLatency:    summarizeLatencies(cfg.metrics.uploads).toJSON(),
+		DownloadLatency:  summarizeLatencies(cfg.metrics.downloads).toJSON(),
+		RoundTripOK:      srcHash == dstHash,
+		SourceSHA256:     srcHash,
+		MergedSHA256:     dstHash,
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	log.Printf("benchmark: %d chunks, %d bytes total", result.ChunkCount, result.TotalBytes)
+	log.Printf("upload:   %.2fs (%.2f MB/s), latency min/median/p95/max = %.1f/%.1f/%.1f/%.1f ms",
+		result.UploadSeconds, result.UploadMBPerSec,
+		result.UploadLatency.MinMS, result.UploadLatency.MedianMS, result.UploadLatency.P95MS, result.UploadLatency.MaxMS)
+	log.Printf("download: %.2fs (%.2f MB/s), latency min/median/p95/max = %.1f/%.1f/%.1f/%.1f ms",
+		result.DownloadSeconds, result.DownloadMBPerSec,
+		result.DownloadLatency.MinMS, result.DownloadLatency.MedianMS, result.DownloadLatency.P95MS, result.DownloadLatency.MaxMS)
+	if result.RoundTripOK {
+		log.Printf("round trip sha256 verified: %s", result.SourceSHA256)
+	} else {
+		log.Printf("round trip sha256 MISMATCH: source %s != merged %s", result.SourceSHA256, result.MergedSHA256)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizeLatencies(t *testing.T) {
+	durations := []time.Duration{
+		50 * time.Millisecond,
+		10 * time.Millisecond,
+		100 * time.Millisecond,
+		20 * time.Millisecond,
+		200 * time.Millisecond,
+	}
+	stats := summarizeLatencies(durations)
+	if stats.Min != 10*time.Millisecond {
+		t.Errorf("Min = %v, want 10ms", stats.Min)
+	}
+	if stats.Max != 200*time.Millisecond {
+		t.Errorf("Max = %v, want 200ms", stats.Max)
+	}
+	if stats.Median != 50*time.Millisecond {
+		t.Errorf("Median = %v, want 50ms", stats.Median)
+	}
+}
+
+func TestSummarizeLatenciesEmpty(t *testing.T) {
+	stats := summarizeLatencies(nil)
+	if stats != (latencyStats{}) {
+		t.Errorf("expected zero-value stats for empty input, got %+v", stats)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func cacheDir(cfg config) string {
+	return filepath.Join(cfg.workDir, "cache")
+}
+
+func cacheBlobPath(cfg config, sha256 string) string {
+	return filepath.Join(cacheDir(cfg), sha256)
+}
+
+// populateCache records ch's already-written local chunk under the cache,
+// keyed by its content hash, so a later run over a slightly modified
+// source (combined with content-defined chunking) can find it again by
+// that hash. The blob is not read back: each run still splits the source
+// file itself.
+func populateCache(cfg config, ch chunkDescriptor) error {
+	if err := os.MkdirAll(cacheDir(cfg), 0o755); err != nil {
+		return err
+	}
+	dst := cacheBlobPath(cfg, ch.sha256)
+	if _, err := os.Stat(dst); err == nil {
+		return touchCacheBlob(cfg, ch.sha256)
+	}
+
+	if runtime.GOOS == "windows" {
+		return copyFile(ch.localPath, dst)
+	}
+	if err := os.Link(ch.localPath, dst); err != nil {
+		return copyFile(ch.localPath, dst)
+	}
+	return nil
+}
+
+// touchCacheBlob bumps sha256's cache blob mtime to now, marking it as
+// recently used so pruneCache's LRU eviction doesn't treat a
+// repeatedly-reused chunk as stale just because it was first cached long
+// ago. A missing blob is not an error: not every caller knows in advance
+// whether the chunk it just confirmed was ever cached locally.
+func touchCacheBlob(cfg config, sha256 string) error {
+	now := time.Now()
+	if err := os.Chtimes(cacheBlobPath(cfg, sha256), now, now); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+type cacheEntry struct {
+	path    string
+	sha256  string
+	size    int64
+	modTime time.Time
+}
+
+func listCacheBlobs(cfg config) ([]cacheEntry, error) {
+	entries, err := os.ReadDir(cacheDir(cfg))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var blobs []cacheEntry
+	for _, e := range entries {
+		if e.IsDir() {
+			continue // skip the "uploaded" marker directory
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, cacheEntry{
+			path:    filepath.Join(cacheDir(cfg), e.Name()),
+			sha256:  e.Name(),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	return blobs, nil
+}
+
+// removeCacheEntry deletes e's blob along with its uploaded markers under
+// every compress mode: the same content hash can have been marked uploaded
+// once raw and once zstd-compressed across different runs against this
+// workdir, and both markers point at a blob that's about to be gone.
+func removeCacheEntry(cfg config, e cacheEntry) (int64, error) {
+	reclaimed := e.size
+	if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	for _, mode := range markerModes {
+		markerPath := filepath.Join(cacheDir(cfg), "uploaded", mode, e.sha256)
+		if info, err := os.Stat(markerPath); err == nil {
+			reclaimed += info.Size()
+			if err := os.Remove(markerPath); err != nil && !os.IsNotExist(err) {
+				return reclaimed, err
+			}
+		}
+	}
+	return reclaimed, nil
+}
+
+// pruneOrphanedMarkers removes uploaded markers whose cache blob is gone
+// and which are older than keepFor, so markers don't accumulate forever
+// for blobs that were already pruned. Markers live under a subdirectory
+// per compress mode, since the same content hash can be marked uploaded
+// under more than one mode.
+func pruneOrphanedMarkers(cfg config, keepFor time.Duration, now time.Time) (int64, int, error) {
+	var reclaimed int64
+	var count int
+	for _, mode := range markerModes {
+		dir := filepath.Join(cacheDir(cfg), "uploaded", mode)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return reclaimed, count, err
+		}
+
+		for _, e := range entries {
+			if _, err := os.Stat(cacheBlobPath(cfg, e.Name())); err == nil {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				return reclaimed, count, err
+			}
+			if now.Sub(info.ModTime()) <= keepFor {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, e.Name())); err != nil && !os.IsNotExist(err) {
+				return reclaimed, count, err
+			}
+			reclaimed += info.Size()
+			count++
+		}
+	}
+	return reclaimed, count, nil
+}
+
+// pruneCache removes cache blobs older than keepFor, then evicts the
+// least-recently-used (by mtime) of whatever remains until the cache is
+// back under maxSize bytes.
+func pruneCache(cfg config, keepFor time.Duration, maxSize int64, now time.Time) (int64, int, error) {
+	blobs, err := listCacheBlobs(cfg)
+	if err != nil {
+		return 0, 0, err
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].modTime.Before(blobs[j].modTime) })
+
+	var reclaimed int64
+	var removed int
+	var kept []cacheEntry
+	for _, b := range blobs {
+		if now.Sub(b.modTime) > keepFor {
+			n, err := removeCacheEntry(cfg, b)
+			if err != nil {
+				return reclaimed, removed, err
+			}
+			reclaimed += n
+			removed++
+			continue
+		}
+		kept = append(kept, b)
+	}
+
+	var total int64
+	for _, b := range kept {
+		total += b.size
+	}
+	for i := 0; total > maxSize && i < len(kept); i++ {
+		n, err := removeCacheEntry(cfg, kept[i])
+		if err != nil {
+			return reclaimed, removed, err
+		}
+		reclaimed += n
+		removed++
+		total -= kept[i].size
+	}
+
+	markerReclaimed, markerRemoved, err := pruneOrphanedMarkers(cfg, keepFor, now)
+	if err != nil {
+		return reclaimed, removed, err
+	}
+	return reclaimed + markerReclaimed, removed + markerRemoved, nil
+}
+
+func runPrune(cfg config) error {
+	maxSize, err := parseSize(cfg.cacheMaxSize)
+	if err != nil {
+		return fmt.Errorf("parsing -max-size: %w", err)
+	}
+	reclaimed, removed, err := pruneCache(cfg, cfg.cacheKeepFor, maxSize, time.Now())
+	if err != nil {
+		return fmt.Errorf("pruning cache: %w", err)
+	}
+	log.Printf("prune: removed %d cache entries, reclaimed %d bytes", removed, reclaimed)
+	return nil
+}
+
+var sizeSuffixes = []struct {
+	suffix string
+	factor int64
+}{
+	{"TB", 1024 * 1024 * 1024 * 1024},
+	{"GB", 1024 * 1024 * 1024},
+	{"MB", 1024 * 1024},
+	{"KB", 1024},
+	{"B", 1},
+}
+
+// parseSize parses human-readable byte sizes like "50GB" or "128KB".
+func parseSize(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return 0, errors.New("size must not be empty")
+	}
+	upper := strings.ToUpper(trimmed)
+	for _, suf := range sizeSuffixes {
+		if strings.HasSuffix(upper, suf.suffix) {
+			numPart := strings.TrimSpace(strings.TrimSuffix(upper, suf.suffix))
+			n, err := strconv.ParseFloat(numPart, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid size %q: %w", s, err)
+			}
+			return int64(n * float64(suf.factor)), nil
+		}
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"50GB", 50 * 1024 * 1024 * 1024, false},
+		{"128KB", 128 * 1024, false},
+		{"1.5MB", int64(1.5 * 1024 * 1024), false},
+		{"100", 100, false},
+		{"", 0, true},
+		{"not-a-size", 0, true},
+	}
+	for _, c := range cases {
+		got, err := parseSize(c.in)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("parseSize(%q): expected an error, got %d", c.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSize(%q): unexpected error: %v", c.in, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseSize(%q) = %d, want %d", c.in, got, c.want)
+		}
+	}
+}
+
+func TestPopulateCacheIsIdempotent(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{workDir: filepath.Join(dir, "work")}
+
+	srcPath := filepath.Join(dir, "chunk.bin")
+	if err := os.WriteFile(srcPath, []byte("some chunk data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ch := chunkDescriptor{localPath: srcPath, sha256: sha256Hex([]byte("some chunk data"))}
+
+	if err := populateCache(cfg, ch); err != nil {
+		t.Fatalf("populateCache: %v", err)
+	}
+	if err := populateCache(cfg, ch); err != nil {
+		t.Fatalf("populateCache (second call): %v", err)
+	}
+
+	blob := cacheBlobPath(cfg, ch.sha256)
+	data, err := os.ReadFile(blob)
+	if err != nil {
+		t.Fatalf("reading cached blob: %v", err)
+	}
+	if string(data) != "some chunk data" {
+		t.Fatalf("cached blob content = %q, want %q", data, "some chunk data")
+	}
+}
+
+func TestPopulateCacheTouchesMtimeOnHit(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{workDir: filepath.Join(dir, "work")}
+
+	srcPath := filepath.Join(dir, "chunk.bin")
+	if err := os.WriteFile(srcPath, []byte("some chunk data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ch := chunkDescriptor{localPath: srcPath, sha256: sha256Hex([]byte("some chunk data"))}
+
+	if err := populateCache(cfg, ch); err != nil {
+		t.Fatalf("populateCache: %v", err)
+	}
+	blob := cacheBlobPath(cfg, ch.sha256)
+	stale := time.Now().Add(-200 * time.Hour)
+	if err := os.Chtimes(blob, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := populateCache(cfg, ch); err != nil {
+		t.Fatalf("populateCache (reuse): %v", err)
+	}
+
+	info, err := os.Stat(blob)
+	if err != nil {
+		t.Fatalf("stat blob: %v", err)
+	}
+	if info.ModTime().Equal(stale) || info.ModTime().Before(stale.Add(time.Hour)) {
+		t.Fatalf("expected populateCache to refresh mtime on reuse, got %v (was %v)", info.ModTime(), stale)
+	}
+}
+
+func TestRemoveCacheEntryRemovesMarkersForEveryCompressMode(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{workDir: filepath.Join(dir, "work")}
+	ch := chunkDescriptor{remoteName: "r-00.bin", sha256: sha256Hex([]byte("chunk"))}
+
+	if err := os.MkdirAll(cacheDir(cfg), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	blob := cacheBlobPath(cfg, ch.sha256)
+	if err := os.WriteFile(blob, []byte("chunk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := markChunkUploaded(cfg, ch, time.Now()); err != nil {
+		t.Fatalf("markChunkUploaded (raw): %v", err)
+	}
+	zstdCfg := config{workDir: cfg.workDir, compress: "zstd"}
+	if err := markChunkUploaded(zstdCfg, ch, time.Now()); err != nil {
+		t.Fatalf("markChunkUploaded (zstd): %v", err)
+	}
+
+	if _, err := removeCacheEntry(cfg, cacheEntry{path: blob, sha256: ch.sha256, size: 5}); err != nil {
+		t.Fatalf("removeCacheEntry: %v", err)
+	}
+
+	for _, mode := range markerModes {
+		path := filepath.Join(cacheDir(cfg), "uploaded", mode, ch.sha256)
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("expected marker %s to be removed, stat err = %v", path, err)
+		}
+	}
+}
+
+func TestPruneCacheEvictsOldAndOversizedEntries(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{workDir: filepath.Join(dir, "work")}
+	if err := os.MkdirAll(cacheDir(cfg), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	write := func(name string, size int, age time.Duration) {
+		path := cacheBlobPath(cfg, name)
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(-age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("old", 10, 200*time.Hour)
+	write("newer", 10, time.Hour)
+	write("newest", 10, time.Minute)
+
+	reclaimed, removed, err := pruneCache(cfg, 72*time.Hour, 15, now)
+	if err != nil {
+		t.Fatalf("pruneCache: %v", err)
+	}
+	// "old" is evicted by age; among the two survivors ("newer", "newest",
+	// 20 bytes total) the least-recently-used ("newer") is evicted to fit
+	// under the 15-byte cap.
+	if removed != 2 {
+		t.Fatalf("removed = %d, want 2", removed)
+	}
+	if reclaimed != 20 {
+		t.Fatalf("reclaimed = %d, want 20", reclaimed)
+	}
+	if _, err := os.Stat(cacheBlobPath(cfg, "newest")); err != nil {
+		t.Fatalf("expected \"newest\" to survive pruning: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	rollingWindowSize = 64
+
+	defaultCDCMinSize    = 128 * 1024
+	defaultCDCAvgSize    = 4 * 1024 * 1024
+	defaultCDCMaxSize    = 16 * 1024 * 1024
+	defaultCDCAvgSizeLog = 22 // log2(4MB)
+)
+
+// gearTable holds the per-byte constants used by the rolling hash below.
+// It is generated once at init time from a fixed seed so the boundaries a
+// given source produces are stable across runs and across machines.
+var gearTable = generateGearTable()
+
+func generateGearTable() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x9e3779b97f4a7c15)
+	for i := range table {
+		state ^= state << 13
+		state ^= state >> 7
+		state ^= state << 17
+		table[i] = state
+	}
+	return table
+}
+
+// splitFileCDC splits source into variable-length chunks using a rolling
+// buzhash-style boundary detector, so that inserting or modifying a region
+// of source only perturbs the chunks touching that region instead of every
+// chunk after it (as a fixed-size splitter would). avgSizeLog controls the
+// expected chunk size: a boundary is declared whenever the low avgSizeLog
+// bits of the rolling hash are zero, clamped to [minSize, maxSize]. Each
+// chunk written is also registered in cfg's content-addressed cache so a
+// later run over a mutated source can reuse unchanged chunks.
+func splitFileCDC(cfg config, source, outDir string, minSize, maxSize int64, avgSizeLog uint) ([]chunkDescriptor, error) {
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mask := uint64(1)<<avgSizeLog - 1
+	reader := bufio.NewReaderSize(file, 4*1024*1024)
+
+	var (
+		descriptors []chunkDescriptor
+		window      [rollingWindowSize]byte
+		windowFill  int
+		windowPos   int
+		hash        uint64
+		chunkBuf    []byte
+		offset      int64
+		index       int
+	)
+
+	flush := func() error {
+		if len(chunkBuf) == 0 {
+			return nil
+		}
+		sum := sha256Hex(chunkBuf)
+		target := filepath.Join(outDir, fmt.Sprintf("chunk-%04d-%s.bin", index, sum[:12]))
+		if err := os.WriteFile(target, chunkBuf, 0o644); err != nil {
+			return err
+		}
+		desc := chunkDescriptor{
+			localPath: target,
+			offset:    offset,
+			length:    int64(len(chunkBuf)),
+			sha256:    sum,
+		}
+		if err := populateCache(cfg, desc); err != nil {
+			return fmt.Errorf("populating cache for %s: %w", target, err)
+		}
+		descriptors = append(descriptors, desc)
+		offset += int64(len(chunkBuf))
+		index++
+		chunkBuf = nil
+		return nil
+	}
+
+	buf := make([]byte, 256*1024)
+	for {
+		n, readErr := reader.Read(buf)
+		for i := 0; i < n; i++ {
+			b := buf[i]
+			chunkBuf = append(chunkBuf, b)
+
+			if windowFill < rollingWindowSize {
+				hash = ((hash << 1) | (hash >> 63)) ^ gearTable[b]
+				window[windowPos] = b
+				windowPos = (windowPos + 1) % rollingWindowSize
+				windowFill++
+			} else {
+				out := window[windowPos]
+				hash = ((hash << 1) | (hash >> 63)) ^ gearTable[b] ^ gearTable[out]
+				window[windowPos] = b
+				windowPos = (windowPos + 1) % rollingWindowSize
+			}
+
+			size := int64(len(chunkBuf))
+			atBoundary := size >= minSize && hash&mask == 0
+			if atBoundary || size >= maxSize {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if readErr != nil {
+			if readErr == io.EOF {
+				break
+			}
+			return nil, readErr
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return descriptors, nil
+}
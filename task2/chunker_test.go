@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeRandomFile creates a deterministically-seeded pseudo-random file so
+// the test is reproducible across runs.
+func writeRandomFile(t *testing.T, path string, size int, seed int64) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+	return data
+}
+
+// testConfig returns a minimal config whose workDir lives under t.TempDir,
+// sufficient for exercising code paths that only need cfg.workDir (e.g. the
+// cache populated by splitFileCDC).
+func testConfig(t *testing.T) config {
+	t.Helper()
+	return config{workDir: filepath.Join(t.TempDir(), "work")}
+}
+
+func chunkHashSet(t *testing.T, descriptors []chunkDescriptor) map[string]bool {
+	t.Helper()
+	set := make(map[string]bool, len(descriptors))
+	for _, d := range descriptors {
+		set[d.sha256] = true
+	}
+	return set
+}
+
+func TestSplitFileCDCStableAcrossLocalMutation(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.bin")
+	const size = 4 * 1024 * 1024
+	data := writeRandomFile(t, source, size, 42)
+
+	outA := filepath.Join(dir, "chunks-a")
+	if err := os.MkdirAll(outA, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	const (
+		minSize    = 16 * 1024
+		maxSize    = 256 * 1024
+		avgSizeLog = 15 // ~32KB average
+	)
+	before, err := splitFileCDC(testConfig(t), source, outA, minSize, maxSize, avgSizeLog)
+	if err != nil {
+		t.Fatalf("splitFileCDC before mutation: %v", err)
+	}
+	if len(before) < 10 {
+		t.Fatalf("expected enough chunks for a meaningful comparison, got %d", len(before))
+	}
+
+	// Mutate a small region well away from the start and end of the file.
+	mutated := make([]byte, len(data))
+	copy(mutated, data)
+	mutationStart := size / 2
+	for i := mutationStart; i < mutationStart+256; i++ {
+		mutated[i] ^= 0xFF
+	}
+	if err := os.WriteFile(source, mutated, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	outB := filepath.Join(dir, "chunks-b")
+	if err := os.MkdirAll(outB, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	after, err := splitFileCDC(testConfig(t), source, outB, minSize, maxSize, avgSizeLog)
+	if err != nil {
+		t.Fatalf("splitFileCDC after mutation: %v", err)
+	}
+
+	beforeSet := chunkHashSet(t, before)
+	afterSet := chunkHashSet(t, after)
+
+	unchanged := 0
+	for h := range beforeSet {
+		if afterSet[h] {
+			unchanged++
+		}
+	}
+	ratio := float64(unchanged) / float64(len(beforeSet))
+	if ratio < 0.9 {
+		t.Fatalf("expected >90%% of chunk hashes unchanged after a local mutation, got %.1f%% (%d/%d)", ratio*100, unchanged, len(beforeSet))
+	}
+}
+
+func TestSplitFileCDCReconstructsSource(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.bin")
+	data := writeRandomFile(t, source, 1024*1024, 7)
+
+	outDir := filepath.Join(dir, "chunks")
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	descriptors, err := splitFileCDC(testConfig(t), source, outDir, 8*1024, 64*1024, 13)
+	if err != nil {
+		t.Fatalf("splitFileCDC: %v", err)
+	}
+
+	var reconstructed []byte
+	for _, d := range descriptors {
+		chunk, err := os.ReadFile(d.localPath)
+		if err != nil {
+			t.Fatalf("reading chunk %s: %v", d.localPath, err)
+		}
+		if int64(len(chunk)) != d.length {
+			t.Fatalf("chunk %s length mismatch: descriptor says %d, file has %d", d.localPath, d.length, len(chunk))
+		}
+		if got := sha256Hex(chunk); got != d.sha256 {
+			t.Fatalf("chunk %s sha256 mismatch: descriptor says %s, got %s", d.localPath, d.sha256, got)
+		}
+		reconstructed = append(reconstructed, chunk...)
+	}
+
+	if len(reconstructed) != len(data) {
+		t.Fatalf("reconstructed size %d != source size %d", len(reconstructed), len(data))
+	}
+	for i := range data {
+		if reconstructed[i] != data[i] {
+			t.Fatalf("reconstructed file diverges from source at byte %d", i)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const tocRemoteSuffix = "-toc.json.zst"
+
+func tocRemoteName(prefix string) string {
+	return prefix + tocRemoteSuffix
+}
+
+func tocLocalPath(cfg config) string {
+	return filepath.Join(cfg.workDir, cfg.remotePrefix+"-toc.json.zst")
+}
+
+// tocEntry mirrors a single chunk's compression bookkeeping, uploaded
+// alongside the chunks themselves so a later download can verify each
+// chunk's compressed bytes before decompressing it.
+type tocEntry struct {
+	Name               string `json:"name"`
+	UncompressedSize   int64  `json:"uncompressedSize"`
+	CompressedSize     int64  `json:"compressedSize"`
+	SHA256Uncompressed string `json:"sha256Uncompressed"`
+	SHA256Compressed   string `json:"sha256Compressed"`
+	OffsetInOriginal   int64  `json:"offsetInOriginal"`
+}
+
+type tableOfContents struct {
+	Entries []tocEntry `json:"entries"`
+}
+
+// compressChunkFile zstd-compresses ch's local chunk into dstPath and
+// returns the toc entry describing both the original and compressed bytes.
+func compressChunkFile(ch chunkDescriptor, dstPath string) (tocEntry, error) {
+	in, err := os.Open(ch.localPath)
+	if err != nil {
+		return tocEntry{}, err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dstPath)
+	if err != nil {
+		return tocEntry{}, err
+	}
+	defer out.Close()
+
+	w, err := zstd.NewWriter(out)
+	if err != nil {
+		return tocEntry{}, err
+	}
+
+	uncompressedHash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, uncompressedHash), in)
+	if err != nil {
+		w.Close()
+		return tocEntry{}, err
+	}
+	if err := w.Close(); err != nil {
+		return tocEntry{}, err
+	}
+
+	compressedHash, compressedSize, err := fileHashAndSize(dstPath)
+	if err != nil {
+		return tocEntry{}, err
+	}
+
+	return tocEntry{
+		Name:               ch.remoteName,
+		UncompressedSize:   n,
+		CompressedSize:     compressedSize,
+		SHA256Uncompressed: hex.EncodeToString(uncompressedHash.Sum(nil)),
+		SHA256Compressed:   compressedHash,
+		OffsetInOriginal:   ch.offset,
+	}, nil
+}
+
+func fileHashAndSize(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+func writeTOC(path string, toc tableOfContents) error {
+	data, err := json.MarshalIndent(toc, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := zstd.NewWriter(f)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func readTOC(path string) (tableOfContents, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return tableOfContents{}, err
+	}
+	defer f.Close()
+
+	r, err := zstd.NewReader(f)
+	if err != nil {
+		return tableOfContents{}, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return tableOfContents{}, err
+	}
+
+	var toc tableOfContents
+	if err := json.Unmarshal(data, &toc); err != nil {
+		return tableOfContents{}, fmt.Errorf("parsing toc: %w", err)
+	}
+	return toc, nil
+}
+
+func tocByName(toc tableOfContents) map[string]tocEntry {
+	byName := make(map[string]tocEntry, len(toc.Entries))
+	for _, e := range toc.Entries {
+		byName[e.Name] = e
+	}
+	return byName
+}
+
+func fetchTOC(ctx context.Context, cfg config) (tableOfContents, error) {
+	localPath := tocLocalPath(cfg)
+	args := append([]string{
+		"download",
+		"--remote-name", tocRemoteName(cfg.remotePrefix),
+		"--output", localPath,
+		"--fragment-size", cfg.fragmentSize,
+	}, cfg.downloadExtra...)
+
+	if err := withRetry(ctx, cfg.maxRetries, cfg.retryBase, func() error {
+		return runClient(ctx, cfg.clientBin, args)
+	}); err != nil {
+		return tableOfContents{}, fmt.Errorf("downloading toc: %w", err)
+	}
+	return readTOC(localPath)
+}
+
+// decompressChunkToFile streams ch's zstd-compressed local file straight
+// into out at ch.offset, verifying the uncompressed sha256 as it goes, so
+// no decompressed copy of the chunk is ever written to disk.
+func decompressChunkToFile(ch chunkDescriptor, out *os.File) error {
+	in, err := os.Open(ch.localPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	r, err := zstd.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if _, err := out.Seek(ch.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), r)
+	if err != nil {
+		return err
+	}
+	if n != ch.length {
+		return fmt.Errorf("chunk %s decompressed to %d bytes, want %d", ch.localPath, n, ch.length)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != ch.sha256 {
+		return fmt.Errorf("chunk %s uncompressed sha256 mismatch: want %s, got %s", ch.localPath, ch.sha256, got)
+	}
+	return nil
+}
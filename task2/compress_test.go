@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompressChunkFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "chunk.bin")
+	data := writeRandomFile(t, source, 256*1024, 99)
+
+	ch := chunkDescriptor{
+		localPath:  source,
+		remoteName: "chunk-00.bin",
+		offset:     0,
+		length:     int64(len(data)),
+		sha256:     sha256Hex(data),
+	}
+
+	compressed := filepath.Join(dir, "chunk.bin.zst")
+	entry, err := compressChunkFile(ch, compressed)
+	if err != nil {
+		t.Fatalf("compressChunkFile: %v", err)
+	}
+	if entry.Name != ch.remoteName {
+		t.Fatalf("entry.Name = %q, want %q", entry.Name, ch.remoteName)
+	}
+	if entry.UncompressedSize != int64(len(data)) {
+		t.Fatalf("entry.UncompressedSize = %d, want %d", entry.UncompressedSize, len(data))
+	}
+	if entry.SHA256Uncompressed != ch.sha256 {
+		t.Fatalf("entry.SHA256Uncompressed = %s, want %s", entry.SHA256Uncompressed, ch.sha256)
+	}
+	wantCompressedHash, wantCompressedSize, err := fileHashAndSize(compressed)
+	if err != nil {
+		t.Fatalf("fileHashAndSize: %v", err)
+	}
+	if entry.SHA256Compressed != wantCompressedHash || entry.CompressedSize != wantCompressedSize {
+		t.Fatalf("entry compressed hash/size = %s/%d, want %s/%d", entry.SHA256Compressed, entry.CompressedSize, wantCompressedHash, wantCompressedSize)
+	}
+
+	compressedCh := ch
+	compressedCh.localPath = compressed
+	out, err := os.Create(filepath.Join(dir, "merged.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	if err := decompressChunkToFile(compressedCh, out); err != nil {
+		t.Fatalf("decompressChunkToFile: %v", err)
+	}
+	got, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(data) {
+		t.Fatalf("decompressed content does not match original")
+	}
+}
+
+func TestDecompressChunkToFileDetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "chunk.bin")
+	data := writeRandomFile(t, source, 64*1024, 100)
+
+	ch := chunkDescriptor{
+		localPath:  source,
+		remoteName: "chunk-00.bin",
+		offset:     0,
+		length:     int64(len(data)),
+		sha256:     sha256Hex(data),
+	}
+
+	compressed := filepath.Join(dir, "chunk.bin.zst")
+	if _, err := compressChunkFile(ch, compressed); err != nil {
+		t.Fatalf("compressChunkFile: %v", err)
+	}
+
+	// Corrupt a byte well inside the compressed frame.
+	raw, err := os.ReadFile(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)/2] ^= 0xFF
+	if err := os.WriteFile(compressed, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	corruptedCh := ch
+	corruptedCh.localPath = compressed
+	out, err := os.Create(filepath.Join(dir, "merged.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	if err := decompressChunkToFile(corruptedCh, out); err == nil {
+		t.Fatal("expected decompressChunkToFile to fail on corrupted compressed bytes, got nil error")
+	}
+}
+
+func TestTOCRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	toc := tableOfContents{Entries: []tocEntry{
+		{Name: "chunk-00.bin", UncompressedSize: 100, CompressedSize: 40, SHA256Uncompressed: "aaa", SHA256Compressed: "bbb", OffsetInOriginal: 0},
+		{Name: "chunk-01.bin", UncompressedSize: 200, CompressedSize: 80, SHA256Uncompressed: "ccc", SHA256Compressed: "ddd", OffsetInOriginal: 100},
+	}}
+
+	path := filepath.Join(dir, "toc.json.zst")
+	if err := writeTOC(path, toc); err != nil {
+		t.Fatalf("writeTOC: %v", err)
+	}
+	got, err := readTOC(path)
+	if err != nil {
+		t.Fatalf("readTOC: %v", err)
+	}
+	if len(got.Entries) != len(toc.Entries) {
+		t.Fatalf("readTOC entries = %d, want %d", len(got.Entries), len(toc.Entries))
+	}
+	for i, e := range toc.Entries {
+		if got.Entries[i] != e {
+			t.Fatalf("entry %d = %+v, want %+v", i, got.Entries[i], e)
+		}
+	}
+
+	byName := tocByName(got)
+	if byName["chunk-01.bin"].SHA256Compressed != "ddd" {
+		t.Fatalf("tocByName lookup failed, got %+v", byName["chunk-01.bin"])
+	}
+}
@@ -12,7 +12,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,27 +25,58 @@ const (
 )
 
 type config struct {
-	sourceFile    string
-	workDir       string
-	chunkDir      string
-	downloadDir   string
-	mergedFile    string
-	chunkSize     int64
-	chunkCount    int
-	clientBin     string
-	remotePrefix  string
-	fragmentSize  string
-	uploadExtra   []string
-	downloadExtra []string
-	skipUpload    bool
-	skipDownload  bool
-	skipVerify    bool
+	sourceFile     string
+	workDir        string
+	chunkDir       string
+	downloadDir    string
+	mergedFile     string
+	chunkSize      int64
+	chunkCount     int
+	chunker        string
+	cdcMinSize     int64
+	cdcAvgSizeLog  uint
+	cdcMaxSize     int64
+	clientBin      string
+	remotePrefix   string
+	fragmentSize   string
+	uploadExtra    []string
+	downloadExtra  []string
+	concurrency    int
+	maxRetries     int
+	retryBase      time.Duration
+	resume         bool
+	statSubcommand string
+	compress       string
+	prune          bool
+	cacheKeepFor   time.Duration
+	cacheMaxSize   string
+	skipUpload     bool
+	skipDownload   bool
+	skipVerify     bool
+
+	// metrics, when non-nil, receives per-chunk upload/download latencies
+	// as uploadChunks/downloadChunks run. Only the benchmark subcommand
+	// sets this; the normal workflow leaves it nil.
+	metrics *benchmarkMetrics
 }
 
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 
+	if len(os.Args) > 1 && os.Args[1] == "benchmark" {
+		if err := runBenchmark(os.Args[2:]); err != nil {
+			log.Fatalf("benchmark failed: %v", err)
+		}
+		return
+	}
+
 	cfg := parseFlags()
+	if cfg.prune {
+		if err := runPrune(cfg); err != nil {
+			log.Fatalf("prune failed: %v", err)
+		}
+		return
+	}
 	if err := run(cfg); err != nil {
 		log.Fatalf("workflow failed: %v", err)
 	}
@@ -50,39 +84,65 @@ func main() {
 
 func parseFlags() config {
 	var (
-		sourceFile    = flag.String("source-file", filepath.Join("data", "source.bin"), "path to the 4GB source file (created if missing)")
-		workDir       = flag.String("work-dir", "workdir", "base working directory")
-		clientBin     = flag.String("client-bin", "0g-storage-client", "path to 0g-storage-client executable")
-		remotePrefix  = flag.String("remote-prefix", "four-gig-demo", "prefix used when naming remote fragments")
-		fragmentSize  = flag.String("fragment-size", "400MB", "fragment size passed to 0g-storage-client")
-		uploadExtra   = flag.String("upload-extra", "", "extra arguments appended to upload commands (space separated)")
-		downloadExtra = flag.String("download-extra", "", "extra arguments appended to download commands (space separated)")
-		chunkCount    = flag.Int("chunk-count", defaultChunkCount, "number of fragments to create")
-		chunkSize     = flag.Int64("chunk-size", defaultChunkSize, "size for each fragment in bytes")
-		skipUpload    = flag.Bool("skip-upload", false, "skip invoking 0g-storage-client upload commands")
-		skipDownload  = flag.Bool("skip-download", false, "skip invoking 0g-storage-client download commands")
-		skipVerify    = flag.Bool("skip-verify", false, "skip checksum comparison between source and merged files")
+		sourceFile     = flag.String("source-file", filepath.Join("data", "source.bin"), "path to the 4GB source file (created if missing)")
+		workDir        = flag.String("work-dir", "workdir", "base working directory")
+		clientBin      = flag.String("client-bin", "0g-storage-client", "path to 0g-storage-client executable")
+		remotePrefix   = flag.String("remote-prefix", "four-gig-demo", "prefix used when naming remote fragments")
+		fragmentSize   = flag.String("fragment-size", "400MB", "fragment size passed to 0g-storage-client")
+		uploadExtra    = flag.String("upload-extra", "", "extra arguments appended to upload commands (space separated)")
+		downloadExtra  = flag.String("download-extra", "", "extra arguments appended to download commands (space separated)")
+		chunkCount     = flag.Int("chunk-count", defaultChunkCount, "number of fragments to create")
+		chunkSize      = flag.Int64("chunk-size", defaultChunkSize, "size for each fragment in bytes")
+		chunker        = flag.String("chunker", "fixed", "chunking strategy: fixed|cdc")
+		cdcMinSize     = flag.Int64("cdc-min-size", defaultCDCMinSize, "minimum chunk size in cdc mode")
+		cdcAvgSizeLog  = flag.Uint("cdc-avg-size-log", defaultCDCAvgSizeLog, "log2 of the target average chunk size in cdc mode")
+		cdcMaxSize     = flag.Int64("cdc-max-size", defaultCDCMaxSize, "maximum chunk size in cdc mode")
+		skipUpload     = flag.Bool("skip-upload", false, "skip invoking 0g-storage-client upload commands")
+		skipDownload   = flag.Bool("skip-download", false, "skip invoking 0g-storage-client download commands")
+		skipVerify     = flag.Bool("skip-verify", false, "skip checksum comparison between source and merged files")
+		concurrency    = flag.Int("concurrency", runtime.NumCPU(), "number of chunks to upload/download in parallel")
+		maxRetries     = flag.Int("max-retries", 3, "maximum retries for a failing chunk operation")
+		retryBase      = flag.Duration("retry-base", 500*time.Millisecond, "base backoff duration between retries (doubles each attempt)")
+		resume         = flag.Bool("resume", true, "skip chunks already uploaded/downloaded according to the manifest; false forces a clean run")
+		statSubcommand = flag.String("stat-subcommand", "", "0g-storage-client subcommand used to check whether a fragment already exists remotely (empty disables remote stat, falling back to local markers)")
+		compress       = flag.String("compress", "", "per-chunk compression: \"\" (none) or \"zstd\"")
+		prune          = flag.Bool("prune", false, "run cache garbage collection instead of the normal upload/download workflow")
+		cacheKeepFor   = flag.Duration("keep-for", 72*time.Hour, "-prune removes cache entries older than this; younger entries are removed only as needed to get under -max-size")
+		cacheMaxSize   = flag.String("max-size", "50GB", "maximum total cache size to retain; -prune evicts the least-recently-used entries beyond this")
 	)
 
 	flag.Parse()
 
 	work := filepath.Clean(*workDir)
 	return config{
-		sourceFile:    filepath.Clean(*sourceFile),
-		workDir:       work,
-		chunkDir:      filepath.Join(work, "chunks"),
-		downloadDir:   filepath.Join(work, "downloads"),
-		mergedFile:    filepath.Join(work, "merged.bin"),
-		chunkSize:     *chunkSize,
-		chunkCount:    *chunkCount,
-		clientBin:     *clientBin,
-		remotePrefix:  *remotePrefix,
-		fragmentSize:  *fragmentSize,
-		uploadExtra:   splitArgs(*uploadExtra),
-		downloadExtra: splitArgs(*downloadExtra),
-		skipUpload:    *skipUpload,
-		skipDownload:  *skipDownload,
-		skipVerify:    *skipVerify,
+		sourceFile:     filepath.Clean(*sourceFile),
+		workDir:        work,
+		chunkDir:       filepath.Join(work, "chunks"),
+		downloadDir:    filepath.Join(work, "downloads"),
+		mergedFile:     filepath.Join(work, "merged.bin"),
+		chunkSize:      *chunkSize,
+		chunkCount:     *chunkCount,
+		chunker:        *chunker,
+		cdcMinSize:     *cdcMinSize,
+		cdcAvgSizeLog:  *cdcAvgSizeLog,
+		cdcMaxSize:     *cdcMaxSize,
+		clientBin:      *clientBin,
+		remotePrefix:   *remotePrefix,
+		fragmentSize:   *fragmentSize,
+		uploadExtra:    splitArgs(*uploadExtra),
+		downloadExtra:  splitArgs(*downloadExtra),
+		skipUpload:     *skipUpload,
+		skipDownload:   *skipDownload,
+		skipVerify:     *skipVerify,
+		concurrency:    *concurrency,
+		maxRetries:     *maxRetries,
+		retryBase:      *retryBase,
+		resume:         *resume,
+		statSubcommand: *statSubcommand,
+		compress:       *compress,
+		prune:          *prune,
+		cacheKeepFor:   *cacheKeepFor,
+		cacheMaxSize:   *cacheMaxSize,
 	}
 }
 
@@ -93,28 +153,59 @@ func run(cfg config) error {
 	if cfg.chunkSize <= 0 {
 		return errors.New("chunk-size must be positive")
 	}
-	totalSize := int64(cfg.chunkCount) * cfg.chunkSize
-
+	if cfg.chunker != "fixed" && cfg.chunker != "cdc" {
+		return fmt.Errorf("unknown chunker %q: must be fixed or cdc", cfg.chunker)
+	}
+	if cfg.compress != "" && cfg.compress != "zstd" {
+		return fmt.Errorf("unknown compress mode %q: must be empty or zstd", cfg.compress)
+	}
 	if err := os.MkdirAll(filepath.Dir(cfg.sourceFile), 0o755); err != nil {
 		return fmt.Errorf("creating source dir: %w", err)
 	}
-	for _, dir := range []string{cfg.workDir, cfg.chunkDir, cfg.downloadDir} {
+	for _, dir := range []string{cfg.workDir, cfg.chunkDir, cfg.downloadDir, cacheDir(cfg)} {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
 			return fmt.Errorf("creating work dir %s: %w", dir, err)
 		}
 	}
 
-	if err := ensureSourceFile(cfg.sourceFile, totalSize); err != nil {
-		return fmt.Errorf("ensuring source file: %w", err)
+	var chunks []chunkDescriptor
+	var err error
+	if cfg.chunker == "cdc" {
+		// cdc is meant to run against an arbitrary, already-sized source
+		// that changes over time, so its size isn't pinned to
+		// chunk-count*chunk-size the way the fixed chunker's demo file is.
+		if _, err := ensureSourceFileCDC(cfg.sourceFile, int64(cfg.chunkCount)*cfg.chunkSize); err != nil {
+			return fmt.Errorf("ensuring source file: %w", err)
+		}
+		chunks, err = splitFileCDC(cfg, cfg.sourceFile, cfg.chunkDir, cfg.cdcMinSize, cfg.cdcMaxSize, cfg.cdcAvgSizeLog)
+	} else {
+		totalSize := int64(cfg.chunkCount) * cfg.chunkSize
+		if err := ensureSourceFile(cfg.sourceFile, totalSize); err != nil {
+			return fmt.Errorf("ensuring source file: %w", err)
+		}
+		chunks, err = splitFile(cfg, cfg.sourceFile, cfg.chunkDir, cfg.chunkSize, cfg.chunkCount)
 	}
-
-	chunks, err := splitFile(cfg.sourceFile, cfg.chunkDir, cfg.chunkSize, cfg.chunkCount)
 	if err != nil {
 		return fmt.Errorf("splitting file: %w", err)
 	}
-	log.Printf("created %d fragments under %s", len(chunks), cfg.chunkDir)
+	log.Printf("created %d fragments under %s using %s chunker", len(chunks), cfg.chunkDir, cfg.chunker)
 
 	meta := makeChunkMeta(cfg.remotePrefix, chunks)
+
+	srcHash, err := fileHash(cfg.sourceFile)
+	if err != nil {
+		return fmt.Errorf("hashing source file: %w", err)
+	}
+
+	if !cfg.resume {
+		log.Println("resume is disabled; ignoring any existing upload/download markers")
+	}
+
+	manifestPath := filepath.Join(cfg.workDir, "manifest.json")
+	if err := writeManifest(manifestPath, buildManifest(cfg.chunker, srcHash, sumChunkLengths(meta), meta)); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Hour)
 	defer cancel()
 
@@ -143,7 +234,7 @@ func run(cfg config) error {
 		}
 	}
 
-	merged, err := mergeChunks(downloaded, cfg.mergedFile)
+	merged, err := mergeChunks(downloaded, cfg.mergedFile, cfg.compress == "zstd")
 	if err != nil {
 		return fmt.Errorf("merging fragments: %w", err)
 	}
@@ -154,10 +245,6 @@ func run(cfg config) error {
 		return nil
 	}
 
-	srcHash, err := fileHash(cfg.sourceFile)
-	if err != nil {
-		return fmt.Errorf("hashing source file: %w", err)
-	}
 	dstHash, err := fileHash(merged)
 	if err != nil {
 		return fmt.Errorf("hashing merged file: %w", err)
@@ -194,16 +281,47 @@ func ensureSourceFile(path string, size int64) error {
 	return nil
 }
 
-func splitFile(source, outDir string, chunkSize int64, chunkCount int) ([]string, error) {
+// ensureSourceFileCDC is ensureSourceFile's cdc-chunker counterpart: unlike
+// the fixed chunker, cdc is meant to run against an arbitrary, already-sized
+// source that changes over time, not just the synthetic fixed-size demo
+// file, so any existing file is accepted as-is. fallbackSize is only used
+// to create a fresh demo file when none exists yet. It returns the file's
+// actual size, which need not match fallbackSize.
+func ensureSourceFileCDC(path string, fallbackSize int64) (int64, error) {
+	info, err := os.Stat(path)
+	if err == nil {
+		log.Printf("source file already present at %s (%d bytes)", path, info.Size())
+		return info.Size(), nil
+	}
+	if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	log.Printf("creating sparse file at %s (%d bytes)", path, fallbackSize)
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err := f.Truncate(fallbackSize); err != nil {
+		return 0, err
+	}
+	return fallbackSize, nil
+}
+
+// splitFile splits source into chunkCount fixed-size chunks under outDir,
+// registering each one in cfg's content-addressed cache as it is written.
+func splitFile(cfg config, source, outDir string, chunkSize int64, chunkCount int) ([]chunkDescriptor, error) {
 	file, err := os.Open(source)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	var paths []string
+	var descriptors []chunkDescriptor
 	bufferSize := int64(8 * 1024 * 1024)
 	buf := make([]byte, bufferSize)
+	var offset int64
 
 	for i := 0; i < chunkCount; i++ {
 		target := filepath.Join(outDir, fmt.Sprintf("chunk-%02d.bin", i))
@@ -212,6 +330,7 @@ func splitFile(source, outDir string, chunkSize int64, chunkCount int) ([]string
 			return nil, err
 		}
 
+		h := sha256.New()
 		var written int64
 		for written < chunkSize {
 			toRead := bufferSize
@@ -224,6 +343,7 @@ func splitFile(source, outDir string, chunkSize int64, chunkCount int) ([]string
 					out.Close()
 					return nil, err
 				}
+				h.Write(buf[:n])
 				written += int64(n)
 			}
 			if readErr != nil {
@@ -235,48 +355,244 @@ func splitFile(source, outDir string, chunkSize int64, chunkCount int) ([]string
 			}
 		}
 		out.Close()
-		paths = append(paths, target)
+		desc := chunkDescriptor{
+			localPath: target,
+			offset:    offset,
+			length:    written,
+			sha256:    hex.EncodeToString(h.Sum(nil)),
+		}
+		if err := populateCache(cfg, desc); err != nil {
+			return nil, fmt.Errorf("populating cache for %s: %w", target, err)
+		}
+		descriptors = append(descriptors, desc)
+		offset += written
 	}
 
-	return paths, nil
+	return descriptors, nil
 }
 
 type chunkDescriptor struct {
 	localPath  string
 	remoteName string
+	offset     int64
+	length     int64
+	sha256     string
 }
 
-func makeChunkMeta(prefix string, localPaths []string) []chunkDescriptor {
-	meta := make([]chunkDescriptor, len(localPaths))
-	for i, p := range localPaths {
-		meta[i] = chunkDescriptor{
-			localPath:  p,
-			remoteName: fmt.Sprintf("%s-%02d.bin", prefix, i),
-		}
+func makeChunkMeta(prefix string, chunks []chunkDescriptor) []chunkDescriptor {
+	meta := make([]chunkDescriptor, len(chunks))
+	for i, ch := range chunks {
+		ch.remoteName = fmt.Sprintf("%s-%02d.bin", prefix, i)
+		meta[i] = ch
 	}
 	return meta
 }
 
-func uploadChunks(ctx context.Context, cfg config, chunks []chunkDescriptor) error {
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sumChunkLengths returns the total size the chunks add up to, for
+// recording in the manifest; unlike chunk-count*chunk-size this is correct
+// for both chunkers, since cdc's chunk count and sizes aren't derived from
+// those flags at all.
+func sumChunkLengths(chunks []chunkDescriptor) int64 {
+	var total int64
 	for _, ch := range chunks {
+		total += ch.length
+	}
+	return total
+}
+
+func uploadChunks(ctx context.Context, cfg config, chunks []chunkDescriptor) error {
+	labels := make([]string, len(chunks))
+	totals := make([]int64, len(chunks))
+	for i, ch := range chunks {
+		labels[i] = ch.remoteName
+		totals[i] = ch.length
+	}
+	display := newProgressDisplay(labels, totals)
+	rate := &rateEstimator{}
+
+	var tocEntries []tocEntry
+	if cfg.compress == "zstd" {
+		tocEntries = make([]tocEntry, len(chunks))
+		// A prior run's toc already has entries for any chunk we're about
+		// to skip below, so a full resume doesn't have to recompress
+		// anything just to rebuild a toc no download will ever see change.
+		if prevTOC, err := readTOC(tocLocalPath(cfg)); err == nil {
+			prev := tocByName(prevTOC)
+			for i, ch := range chunks {
+				if entry, ok := prev[ch.remoteName]; ok {
+					tocEntries[i] = entry
+				}
+			}
+		}
+	}
+
+	var anyUploaded int32
+	err := runWorkerPool(ctx, cfg.concurrency, len(chunks), func(ctx context.Context, i int) error {
+		ch := chunks[i]
+
+		if cfg.resume {
+			match, err := localFileMatchesSHA(ch.localPath, ch.sha256)
+			if err != nil {
+				return fmt.Errorf("checking local chunk %s: %w", ch.localPath, err)
+			}
+			if match {
+				uploaded, err := isChunkUploaded(ctx, cfg, ch)
+				if err != nil {
+					return fmt.Errorf("checking upload status of %s: %w", ch.remoteName, err)
+				}
+				if uploaded {
+					log.Printf("skip upload (resume): %s already present as %s", ch.localPath, ch.remoteName)
+					display.setDone(i, ch.length)
+					return nil
+				}
+			}
+		}
+
+		uploadPath := ch.localPath
+		uploadSize := ch.length
+		if cfg.compress == "zstd" {
+			compressedPath := ch.localPath + ".zst"
+			entry, err := compressChunkFile(ch, compressedPath)
+			if err != nil {
+				return fmt.Errorf("compressing %s: %w", ch.localPath, err)
+			}
+			tocEntries[i] = entry
+			uploadPath = compressedPath
+			uploadSize = entry.CompressedSize
+		}
+
 		args := append([]string{
 			"upload",
-			"--file", ch.localPath,
+			"--file", uploadPath,
 			"--remote-name", ch.remoteName,
 			"--fragment-size", cfg.fragmentSize,
 		}, cfg.uploadExtra...)
-		log.Printf("uploading %s -> %s", ch.localPath, ch.remoteName)
-		if err := runClient(ctx, cfg.clientBin, args); err != nil {
+		log.Printf("uploading %s -> %s", uploadPath, ch.remoteName)
+
+		start := time.Now()
+		// The local file is already fully formed before the transfer
+		// starts, so there's nothing to poll; animate from this run's
+		// observed rate instead (a no-op until a chunk has completed).
+		stopAnimating := rate.animate(display, i, uploadSize, start)
+		err := withRetry(ctx, cfg.maxRetries, cfg.retryBase, func() error {
+			return runClient(ctx, cfg.clientBin, args)
+		})
+		stopAnimating()
+		if err != nil {
 			return err
 		}
+		elapsed := time.Since(start)
+		display.setDone(i, uploadSize)
+		rate.record(uploadSize, elapsed)
+		log.Printf("uploaded %s: %d bytes in %v (%.2f MB/s)", ch.remoteName, uploadSize, elapsed, bytesPerSec(uploadSize, elapsed))
+		if cfg.metrics != nil {
+			cfg.metrics.recordUpload(elapsed)
+		}
+
+		if err := markChunkUploaded(cfg, ch, time.Now()); err != nil {
+			return fmt.Errorf("marking %s uploaded: %w", ch.remoteName, err)
+		}
+		atomic.StoreInt32(&anyUploaded, 1)
+		return nil
+	})
+	display.finish()
+	if err != nil {
+		return err
+	}
+
+	// anyUploaded only latches when a chunk actually ran the upload branch
+	// above. With no -stat-subcommand, that means isChunkUploaded's
+	// zstd-scoped marker said no prior zstd upload existed for it, so "skip
+	// toc re-upload" below can only fire once every chunk's zstd marker
+	// already existed — never because a differently-compressed (e.g. raw)
+	// run's markers were mistaken for it. With a -stat-subcommand, the
+	// remote stat checks only the remote name, which is the same under
+	// either compress mode.
+	if cfg.compress == "zstd" && atomic.LoadInt32(&anyUploaded) == 1 {
+		if err := writeTOC(tocLocalPath(cfg), tableOfContents{Entries: tocEntries}); err != nil {
+			return fmt.Errorf("writing toc: %w", err)
+		}
+		args := append([]string{
+			"upload",
+			"--file", tocLocalPath(cfg),
+			"--remote-name", tocRemoteName(cfg.remotePrefix),
+			"--fragment-size", cfg.fragmentSize,
+		}, cfg.uploadExtra...)
+		if err := withRetry(ctx, cfg.maxRetries, cfg.retryBase, func() error {
+			return runClient(ctx, cfg.clientBin, args)
+		}); err != nil {
+			return fmt.Errorf("uploading toc: %w", err)
+		}
+		log.Printf("uploaded toc -> %s", tocRemoteName(cfg.remotePrefix))
+	} else if cfg.compress == "zstd" {
+		log.Println("resume: all chunks already uploaded, skipping toc re-upload")
 	}
 	return nil
 }
 
 func downloadChunks(ctx context.Context, cfg config, chunks []chunkDescriptor) ([]chunkDescriptor, error) {
-	var result []chunkDescriptor
-	for _, ch := range chunks {
+	labels := make([]string, len(chunks))
+	totals := make([]int64, len(chunks))
+	for i, ch := range chunks {
+		labels[i] = ch.remoteName
+		totals[i] = ch.length
+	}
+	display := newProgressDisplay(labels, totals)
+
+	if cfg.resume {
+		missing := missingChunkIndices(cfg, chunks)
+		log.Printf("resume: %d/%d chunks already present locally, %d to download", len(chunks)-len(missing), len(chunks), len(missing))
+	}
+
+	var tocEntries map[string]tocEntry
+	if cfg.compress == "zstd" {
+		toc, err := fetchTOC(ctx, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("fetching toc: %w", err)
+		}
+		tocEntries = tocByName(toc)
+	}
+
+	result := make([]chunkDescriptor, len(chunks))
+	err := runWorkerPool(ctx, cfg.concurrency, len(chunks), func(ctx context.Context, i int) error {
+		ch := chunks[i]
 		target := filepath.Join(cfg.downloadDir, filepath.Base(ch.remoteName))
+		wantSHA := ch.sha256
+		wantSize := ch.length
+		if cfg.compress == "zstd" {
+			target += ".zst"
+			entry, ok := tocEntries[ch.remoteName]
+			if !ok {
+				return fmt.Errorf("chunk %s missing from toc", ch.remoteName)
+			}
+			wantSHA = entry.SHA256Compressed
+			wantSize = entry.CompressedSize
+		}
+
+		if cfg.resume {
+			match, err := localFileMatchesSHA(target, wantSHA)
+			if err != nil {
+				return fmt.Errorf("checking downloaded chunk %s: %w", target, err)
+			}
+			if match {
+				log.Printf("skip download (resume): %s already present at %s", ch.remoteName, target)
+				display.setDone(i, ch.length)
+				result[i] = chunkDescriptor{
+					localPath:  target,
+					remoteName: ch.remoteName,
+					offset:     ch.offset,
+					length:     ch.length,
+					sha256:     ch.sha256,
+				}
+				return nil
+			}
+		}
+
 		args := append([]string{
 			"download",
 			"--remote-name", ch.remoteName,
@@ -284,34 +600,95 @@ func downloadChunks(ctx context.Context, cfg config, chunks []chunkDescriptor) (
 			"--fragment-size", cfg.fragmentSize,
 		}, cfg.downloadExtra...)
 		log.Printf("downloading %s -> %s", ch.remoteName, target)
-		if err := runClient(ctx, cfg.clientBin, args); err != nil {
-			return nil, err
+
+		start := time.Now()
+		// target is written progressively by the client as bytes arrive,
+		// so poll its growing size for live feedback instead of only
+		// finding out once the whole transfer has finished.
+		stopPolling := pollGrowingFile(target, 250*time.Millisecond, func(n int64) { display.setDone(i, n) })
+		err := withRetry(ctx, cfg.maxRetries, cfg.retryBase, func() error {
+			if err := runClient(ctx, cfg.clientBin, args); err != nil {
+				return err
+			}
+			if cfg.compress == "zstd" {
+				match, err := localFileMatchesSHA(target, wantSHA)
+				if err != nil {
+					return err
+				}
+				if !match {
+					return fmt.Errorf("chunk %s failed compressed sha256 verification", ch.remoteName)
+				}
+			}
+			return nil
+		})
+		stopPolling()
+		if err != nil {
+			return err
+		}
+		elapsed := time.Since(start)
+		display.setDone(i, wantSize)
+		log.Printf("downloaded %s: %d bytes in %v (%.2f MB/s)", ch.remoteName, wantSize, elapsed, bytesPerSec(wantSize, elapsed))
+		if cfg.metrics != nil {
+			cfg.metrics.recordDownload(elapsed)
 		}
-		result = append(result, chunkDescriptor{
+
+		result[i] = chunkDescriptor{
 			localPath:  target,
 			remoteName: ch.remoteName,
-		})
+			offset:     ch.offset,
+			length:     ch.length,
+			sha256:     ch.sha256,
+		}
+		return nil
+	})
+	display.finish()
+	if err != nil {
+		return nil, err
 	}
 	return result, nil
 }
 
-func mergeChunks(chunks []chunkDescriptor, target string) (string, error) {
+// mergeChunks assembles target by writing each chunk at its recorded
+// offset, so the result is correct regardless of the order chunks arrive
+// in (e.g. after a resumed, partially out-of-order download). When
+// compressed is set, chunks are zstd-compressed on disk and are
+// decompressed straight into target, so no decompressed copy is ever
+// written to disk.
+func mergeChunks(chunks []chunkDescriptor, target string, compressed bool) (string, error) {
+	ordered := make([]chunkDescriptor, len(chunks))
+	copy(ordered, chunks)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].offset < ordered[j].offset })
+
 	out, err := os.Create(target)
 	if err != nil {
 		return "", err
 	}
 	defer out.Close()
 
-	for _, ch := range chunks {
+	for _, ch := range ordered {
+		if compressed {
+			if err := decompressChunkToFile(ch, out); err != nil {
+				return "", err
+			}
+			continue
+		}
+
 		in, err := os.Open(ch.localPath)
 		if err != nil {
 			return "", err
 		}
-		if _, err := io.Copy(out, in); err != nil {
+		if _, err := out.Seek(ch.offset, io.SeekStart); err != nil {
 			in.Close()
 			return "", err
 		}
+		n, err := io.Copy(out, in)
 		in.Close()
+		if err != nil {
+			return "", err
+		}
+		if n != ch.length {
+			return "", fmt.Errorf("chunk %s wrote %d bytes, expected %d", ch.localPath, n, ch.length)
+		}
 	}
 	return target, nil
 }
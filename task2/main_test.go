@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnsureSourceFileCDCAcceptsExistingArbitrarySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "source.bin")
+	data := writeRandomFile(t, path, 12345, 1)
+
+	size, err := ensureSourceFileCDC(path, 999999)
+	if err != nil {
+		t.Fatalf("ensureSourceFileCDC: %v", err)
+	}
+	if size != int64(len(data)) {
+		t.Fatalf("size = %d, want %d", size, len(data))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(data)) {
+		t.Fatalf("ensureSourceFileCDC must not resize an existing file, got %d want %d", info.Size(), len(data))
+	}
+}
+
+func TestEnsureSourceFileCDCCreatesFallbackWhenMissing(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "source.bin")
+
+	size, err := ensureSourceFileCDC(path, 4096)
+	if err != nil {
+		t.Fatalf("ensureSourceFileCDC: %v", err)
+	}
+	if size != 4096 {
+		t.Fatalf("size = %d, want 4096", size)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 4096 {
+		t.Fatalf("created file size = %d, want 4096", info.Size())
+	}
+}
+
+// TestCDCSourcePreparationIgnoresChunkCountTimesChunkSize reproduces the
+// reviewer's exact scenario: an arbitrary pre-existing source file whose
+// size has nothing to do with chunk-count*chunk-size must still work under
+// the cdc chunker, and the resulting manifest's TotalSize must reflect the
+// actual chunk descriptors rather than that bogus product.
+func TestCDCSourcePreparationIgnoresChunkCountTimesChunkSize(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.bin")
+	const sourceSize = 777777 // deliberately not a multiple of any chunkCount*chunkSize below
+	writeRandomFile(t, source, sourceSize, 123)
+
+	cfg := config{chunkCount: 4, chunkSize: 1024, workDir: filepath.Join(dir, "work")}
+	bogusFallback := int64(cfg.chunkCount) * cfg.chunkSize // 4096, nowhere near sourceSize
+
+	size, err := ensureSourceFileCDC(source, bogusFallback)
+	if err != nil {
+		t.Fatalf("ensureSourceFileCDC: %v", err)
+	}
+	if size != sourceSize {
+		t.Fatalf("ensureSourceFileCDC size = %d, want %d (must not be gated by chunk-count*chunk-size)", size, sourceSize)
+	}
+
+	outDir := filepath.Join(dir, "chunks")
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := splitFileCDC(cfg, source, outDir, 8*1024, 64*1024, 13)
+	if err != nil {
+		t.Fatalf("splitFileCDC: %v", err)
+	}
+
+	m := buildManifest(cfg.chunker, "srchash", sumChunkLengths(chunks), chunks)
+	if m.TotalSize != sourceSize {
+		t.Fatalf("manifest.TotalSize = %d, want %d (actual source size, not chunk-count*chunk-size = %d)", m.TotalSize, sourceSize, bogusFallback)
+	}
+}
+
+func TestSumChunkLengths(t *testing.T) {
+	chunks := []chunkDescriptor{{length: 10}, {length: 20}, {length: 7}}
+	if got := sumChunkLengths(chunks); got != 37 {
+		t.Fatalf("sumChunkLengths = %d, want 37", got)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// manifest is the on-disk record of a split, written to workdir/manifest.json
+// right after splitFile/splitFileCDC for inspection and debugging. It is not
+// itself consulted to decide what to skip on resume: that's driven by
+// per-chunk content hashes, via the .uploaded markers (isChunkUploaded) and
+// local-file comparisons (missingChunkIndices) below.
+type manifest struct {
+	SourceSHA256 string          `json:"source_sha256"`
+	TotalSize    int64           `json:"total_size"`
+	Chunker      string          `json:"chunker"`
+	Chunks       []manifestChunk `json:"chunks"`
+}
+
+type manifestChunk struct {
+	Index      int    `json:"index"`
+	LocalPath  string `json:"local_path"`
+	RemoteName string `json:"remote_name"`
+	Offset     int64  `json:"offset"`
+	Length     int64  `json:"length"`
+	SHA256     string `json:"sha256"`
+}
+
+func buildManifest(chunker, sourceHash string, totalSize int64, chunks []chunkDescriptor) manifest {
+	m := manifest{
+		SourceSHA256: sourceHash,
+		TotalSize:    totalSize,
+		Chunker:      chunker,
+		Chunks:       make([]manifestChunk, len(chunks)),
+	}
+	for i, ch := range chunks {
+		m.Chunks[i] = manifestChunk{
+			Index:      i,
+			LocalPath:  ch.localPath,
+			RemoteName: ch.remoteName,
+			Offset:     ch.offset,
+			Length:     ch.length,
+			SHA256:     ch.sha256,
+		}
+	}
+	return m
+}
+
+func writeManifest(path string, m manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// loadManifest returns nil, nil if path does not exist.
+func loadManifest(path string) (*manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// localFileMatchesSHA reports whether path exists and hashes to want. A
+// missing file is reported as a clean "no match" rather than an error.
+func localFileMatchesSHA(path, want string) (bool, error) {
+	sum, err := fileHash(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return sum == want, nil
+}
+
+// missingChunkIndices returns the indices of chunks whose download target
+// does not yet exist locally with the expected content hash.
+func missingChunkIndices(cfg config, chunks []chunkDescriptor) []int {
+	var missing []int
+	for i, ch := range chunks {
+		target := filepath.Join(cfg.downloadDir, filepath.Base(ch.remoteName))
+		match, err := localFileMatchesSHA(target, ch.sha256)
+		if err != nil || !match {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// markerMode returns the on-disk namespace segment for cfg.compress, so an
+// upload recorded under one compression mode is never mistaken for an
+// upload of the same content under a different one: a raw chunk and its
+// zstd-compressed counterpart have the same sha256 (it's hashed before
+// compression) but are different remote objects.
+func markerMode(compress string) string {
+	if compress == "" {
+		return "raw"
+	}
+	return compress
+}
+
+// markerModes lists every namespace segment markerMode can produce, for
+// callers that need to look across all compression modes for a given
+// content hash (e.g. when evicting a cache blob).
+var markerModes = []string{"raw", "zstd"}
+
+func uploadedMarkerPath(cfg config, sha256 string) string {
+	return filepath.Join(cacheDir(cfg), "uploaded", markerMode(cfg.compress), sha256)
+}
+
+type uploadedMarker struct {
+	RemoteName string    `json:"remote_name"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+func markChunkUploaded(cfg config, ch chunkDescriptor, at time.Time) error {
+	dir := filepath.Dir(uploadedMarkerPath(cfg, ch.sha256))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(uploadedMarker{RemoteName: ch.remoteName, UploadedAt: at})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(uploadedMarkerPath(cfg, ch.sha256), data, 0o644)
+}
+
+// statChunk asks 0g-storage-client whether remoteName already exists
+// remotely, using cfg.statSubcommand. It returns false, nil (rather than an
+// error) when the client exits non-zero, since that's how these tools
+// typically report "not found".
+func statChunk(ctx context.Context, cfg config, remoteName string) (bool, error) {
+	args := []string{cfg.statSubcommand, "--remote-name", remoteName}
+	err := runClient(ctx, cfg.clientBin, args)
+	if err == nil {
+		return true, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return false, nil
+	}
+	return false, err
+}
+
+// isChunkUploaded reports whether ch has already been uploaded, preferring
+// a remote stat (via cfg.statSubcommand) and falling back to a local
+// .uploaded marker keyed by content hash and compress mode when no stat
+// subcommand is configured, since a raw and a zstd-compressed upload of the
+// same content hash are different remote objects. A positive result
+// touches ch's cache blob mtime, since this is the "chunk reused" case the
+// cache's LRU eviction is meant to track.
+func isChunkUploaded(ctx context.Context, cfg config, ch chunkDescriptor) (bool, error) {
+	if cfg.statSubcommand != "" {
+		uploaded, err := statChunk(ctx, cfg, ch.remoteName)
+		if err != nil || !uploaded {
+			return uploaded, err
+		}
+		return true, touchCacheBlob(cfg, ch.sha256)
+	}
+	if _, err := os.Stat(uploadedMarkerPath(cfg, ch.sha256)); err == nil {
+		return true, touchCacheBlob(cfg, ch.sha256)
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+	return false, nil
+}
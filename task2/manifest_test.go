@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteLoadManifestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.json")
+
+	chunks := []chunkDescriptor{
+		{localPath: "a.bin", remoteName: "r-00.bin", offset: 0, length: 10, sha256: "aaa"},
+		{localPath: "b.bin", remoteName: "r-01.bin", offset: 10, length: 20, sha256: "bbb"},
+	}
+	want := buildManifest("cdc", "srchash", 30, chunks)
+
+	if err := writeManifest(path, want); err != nil {
+		t.Fatalf("writeManifest: %v", err)
+	}
+	got, err := loadManifest(path)
+	if err != nil {
+		t.Fatalf("loadManifest: %v", err)
+	}
+	if got == nil {
+		t.Fatal("loadManifest returned nil for an existing file")
+	}
+	if got.SourceSHA256 != want.SourceSHA256 || got.TotalSize != want.TotalSize || got.Chunker != want.Chunker {
+		t.Fatalf("loadManifest = %+v, want %+v", got, want)
+	}
+	if len(got.Chunks) != len(want.Chunks) {
+		t.Fatalf("loadManifest chunks = %d, want %d", len(got.Chunks), len(want.Chunks))
+	}
+}
+
+func TestLoadManifestMissingFileReturnsNil(t *testing.T) {
+	m, err := loadManifest(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m != nil {
+		t.Fatalf("expected nil manifest for a missing file, got %+v", m)
+	}
+}
+
+func TestMissingChunkIndices(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{downloadDir: dir}
+
+	present := chunkDescriptor{remoteName: "present.bin", sha256: sha256Hex([]byte("present"))}
+	if err := os.WriteFile(filepath.Join(dir, "present.bin"), []byte("present"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stale := chunkDescriptor{remoteName: "stale.bin", sha256: sha256Hex([]byte("want"))}
+	if err := os.WriteFile(filepath.Join(dir, "stale.bin"), []byte("got"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	absent := chunkDescriptor{remoteName: "absent.bin", sha256: sha256Hex([]byte("absent"))}
+
+	got := missingChunkIndices(cfg, []chunkDescriptor{present, stale, absent})
+	if want := []int{1, 2}; !intSlicesEqual(got, want) {
+		t.Fatalf("missingChunkIndices = %v, want %v", got, want)
+	}
+}
+
+func intSlicesEqual(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIsChunkUploadedFallsBackToLocalMarker(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{workDir: filepath.Join(dir, "work")}
+	ch := chunkDescriptor{remoteName: "r-00.bin", sha256: sha256Hex([]byte("chunk"))}
+
+	uploaded, err := isChunkUploaded(context.Background(), cfg, ch)
+	if err != nil {
+		t.Fatalf("isChunkUploaded (no marker): %v", err)
+	}
+	if uploaded {
+		t.Fatal("expected isChunkUploaded to report false before markChunkUploaded")
+	}
+
+	if err := markChunkUploaded(cfg, ch, time.Now()); err != nil {
+		t.Fatalf("markChunkUploaded: %v", err)
+	}
+
+	uploaded, err = isChunkUploaded(context.Background(), cfg, ch)
+	if err != nil {
+		t.Fatalf("isChunkUploaded (after marker): %v", err)
+	}
+	if !uploaded {
+		t.Fatal("expected isChunkUploaded to report true after markChunkUploaded")
+	}
+}
+
+func TestIsChunkUploadedIsScopedToCompressMode(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{workDir: filepath.Join(dir, "work")}
+	ch := chunkDescriptor{remoteName: "r-00.bin", sha256: sha256Hex([]byte("chunk"))}
+
+	if err := markChunkUploaded(cfg, ch, time.Now()); err != nil {
+		t.Fatalf("markChunkUploaded (raw): %v", err)
+	}
+
+	uploaded, err := isChunkUploaded(context.Background(), cfg, ch)
+	if err != nil {
+		t.Fatalf("isChunkUploaded (raw): %v", err)
+	}
+	if !uploaded {
+		t.Fatal("expected isChunkUploaded to report true for the mode it was marked under")
+	}
+
+	zstdCfg := config{workDir: cfg.workDir, compress: "zstd"}
+	uploaded, err = isChunkUploaded(context.Background(), zstdCfg, ch)
+	if err != nil {
+		t.Fatalf("isChunkUploaded (zstd): %v", err)
+	}
+	if uploaded {
+		t.Fatal("expected a raw-mode marker not to satisfy a zstd-mode upload check")
+	}
+
+	if err := markChunkUploaded(zstdCfg, ch, time.Now()); err != nil {
+		t.Fatalf("markChunkUploaded (zstd): %v", err)
+	}
+	uploaded, err = isChunkUploaded(context.Background(), zstdCfg, ch)
+	if err != nil {
+		t.Fatalf("isChunkUploaded (zstd, after marker): %v", err)
+	}
+	if !uploaded {
+		t.Fatal("expected isChunkUploaded to report true once the zstd-mode marker exists")
+	}
+}
+
+func TestIsChunkUploadedTouchesCacheBlob(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{workDir: filepath.Join(dir, "work")}
+	ch := chunkDescriptor{remoteName: "r-00.bin", sha256: sha256Hex([]byte("chunk"))}
+
+	if err := os.MkdirAll(cacheDir(cfg), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cacheBlobPath(cfg, ch.sha256), []byte("chunk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-200 * time.Hour)
+	if err := os.Chtimes(cacheBlobPath(cfg, ch.sha256), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := markChunkUploaded(cfg, ch, time.Now()); err != nil {
+		t.Fatalf("markChunkUploaded: %v", err)
+	}
+
+	if _, err := isChunkUploaded(context.Background(), cfg, ch); err != nil {
+		t.Fatalf("isChunkUploaded: %v", err)
+	}
+
+	info, err := os.Stat(cacheBlobPath(cfg, ch.sha256))
+	if err != nil {
+		t.Fatalf("stat blob: %v", err)
+	}
+	if info.ModTime().Before(stale.Add(time.Hour)) {
+		t.Fatalf("expected isChunkUploaded to refresh the blob's mtime, got %v (was %v)", info.ModTime(), stale)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// runWorkerPool dispatches work over n items across concurrency goroutines.
+// It stops handing out new items and cancels the derived context as soon as
+// any work call returns an error, but still lets already-started items
+// finish so partial progress isn't lost mid-write.
+func runWorkerPool(ctx context.Context, concurrency, n int, work func(ctx context.Context, i int) error) error {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if concurrency > n {
+		concurrency = n
+	}
+
+	poolCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int)
+	errCh := make(chan error, n)
+	var wg sync.WaitGroup
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				// The feeder's select can still hand out an item after
+				// cancellation; don't start it.
+				if err := poolCtx.Err(); err != nil {
+					errCh <- fmt.Errorf("chunk %d: %w", idx, err)
+					continue
+				}
+				if err := work(poolCtx, idx); err != nil {
+					errCh <- fmt.Errorf("chunk %d: %w", idx, err)
+					cancel()
+					continue
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-poolCtx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errCh)
+
+	var firstErr error
+	for err := range errCh {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// withRetry runs fn, retrying with exponential backoff (base, 2*base,
+// 4*base, ...) up to maxRetries times. It gives up early if ctx is
+// cancelled between attempts.
+func withRetry(ctx context.Context, maxRetries int, base time.Duration, fn func() error) error {
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			wait := base * (1 << uint(attempt-1))
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return lastErr
+			}
+			log.Printf("retrying after error (attempt %d/%d): %v", attempt+1, maxRetries+1, lastErr)
+		}
+		lastErr = fn()
+		if lastErr == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", maxRetries+1, lastErr)
+}
+
+func bytesPerSec(n int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(n) / elapsed.Seconds() / (1024 * 1024)
+}
+
+// chunkProgress tracks bytes transferred for a single chunk out of its
+// known total size.
+type chunkProgress struct {
+	label string
+	total int64
+	done  int64 // atomic
+}
+
+// progressDisplay renders a multi-bar view of all in-flight chunk transfers
+// to stderr, redrawing in place.
+type progressDisplay struct {
+	mu         sync.Mutex
+	bars       []*chunkProgress
+	lastRender time.Time
+	lastLines  int
+}
+
+func newProgressDisplay(labels []string, totals []int64) *progressDisplay {
+	bars := make([]*chunkProgress, len(labels))
+	for i, label := range labels {
+		bars[i] = &chunkProgress{label: label, total: totals[i]}
+	}
+	return &progressDisplay{bars: bars}
+}
+
+// setDone sets chunk i's transferred-bytes count to an absolute value, for
+// callers (live rate estimation, growing-file polling) that compute how
+// much has transferred so far directly rather than reporting each
+// incremental read.
+func (p *progressDisplay) setDone(i int, n int64) {
+	atomic.StoreInt64(&p.bars[i].done, n)
+	p.render(false)
+}
+
+func (p *progressDisplay) finish() {
+	p.render(true)
+}
+
+// pollGrowingFile starts a ticker that stats path every interval and
+// reports its current size to onSize, until the returned stop func is
+// called. It's meant for a download target that the external client
+// writes progressively, so the display has live signal while runClient is
+// still running instead of only updating once it returns. A path that
+// doesn't exist yet (or briefly disappears, e.g. being recreated) is
+// silently skipped rather than treated as an error.
+func pollGrowingFile(path string, interval time.Duration, onSize func(n int64)) func() {
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if info, err := os.Stat(path); err == nil {
+					onSize(info.Size())
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// rateEstimator tracks an aggregate bytes-per-second rate across calls to
+// record, so a transfer with no other live progress signal (e.g. an
+// upload, where the local file is already fully formed before the
+// transfer starts and there's nothing to poll) can animate its bar from
+// an estimate grounded in this run's own previously observed throughput,
+// instead of sitting frozen until the transfer completes.
+type rateEstimator struct {
+	mu    sync.Mutex
+	bytes int64
+	nanos int64
+}
+
+func (r *rateEstimator) record(n int64, elapsed time.Duration) {
+	if elapsed <= 0 {
+		return
+	}
+	r.mu.Lock()
+	r.bytes += n
+	r.nanos += elapsed.Nanoseconds()
+	r.mu.Unlock()
+}
+
+func (r *rateEstimator) bytesPerSec() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.nanos == 0 {
+		return 0
+	}
+	return float64(r.bytes) / (float64(r.nanos) / 1e9)
+}
+
+// animate does nothing and returns a no-op stop func if no rate has been
+// recorded by the time it is called (there's no history to estimate from);
+// otherwise it starts a ticker that projects bytes-transferred-so-far for
+// chunk i from elapsed time and the rate observed at the call, and feeds
+// that into display, until the returned stop func is called. The
+// projection never reaches total, so a slower-than-usual
+// transfer doesn't appear to finish before runClient actually returns.
+func (r *rateEstimator) animate(display *progressDisplay, i int, total int64, start time.Time) func() {
+	rate := r.bytesPerSec()
+	if rate <= 0 {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				estimate := int64(rate * time.Since(start).Seconds())
+				if estimate >= total {
+					estimate = total - 1
+				}
+				display.setDone(i, estimate)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+func (p *progressDisplay) render(force bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !force && time.Since(p.lastRender) < 100*time.Millisecond {
+		return
+	}
+	p.lastRender = time.Now()
+
+	if p.lastLines > 0 {
+		fmt.Fprintf(os.Stderr, "\033[%dA", p.lastLines)
+	}
+	for _, b := range p.bars {
+		done := atomic.LoadInt64(&b.done)
+		pct := 0.0
+		if b.total > 0 {
+			pct = float64(done) / float64(b.total) * 100
+			if pct > 100 {
+				pct = 100
+			}
+		}
+		const width = 24
+		filled := int(pct / 100 * width)
+		bar := strings.Repeat("=", filled) + strings.Repeat("-", width-filled)
+		fmt.Fprintf(os.Stderr, "%-20s [%s] %5.1f%%\n", b.label, bar, pct)
+	}
+	p.lastLines = len(p.bars)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunWorkerPoolPreservesOrder(t *testing.T) {
+	const n = 50
+	result := make([]int, n)
+	err := runWorkerPool(context.Background(), 8, n, func(ctx context.Context, i int) error {
+		result[i] = i * i
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, v := range result {
+		if v != i*i {
+			t.Fatalf("result[%d] = %d, want %d", i, v, i*i)
+		}
+	}
+}
+
+func TestRunWorkerPoolCancelsOnFirstError(t *testing.T) {
+	const n = 20
+	var started int32
+	boom := errors.New("boom")
+
+	err := runWorkerPool(context.Background(), 4, n, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&started, 1)
+		if i == 0 {
+			return boom
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err == nil {
+		t.Fatal("expected an error from the pool")
+	}
+	if got := atomic.LoadInt32(&started); got == int32(n) {
+		t.Fatalf("expected cancellation to prevent all %d items from starting, all ran", n)
+	}
+}
+
+func TestWithRetrySucceedsAfterFailures(t *testing.T) {
+	attempts := 0
+	err := withRetry(context.Background(), 3, time.Millisecond, func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestWithRetryGivesUp(t *testing.T) {
+	attempts := 0
+	err := withRetry(context.Background(), 2, time.Millisecond, func() error {
+		attempts++
+		return errors.New("permanent")
+	})
+	if err == nil {
+		t.Fatal("expected an error once retries are exhausted")
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts (1 initial + 2 retries), got %d", attempts)
+	}
+}
+
+func TestPollGrowingFileReportsIntermediateSizes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "growing.bin")
+	if err := os.WriteFile(path, make([]byte, 10), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var lastSize int64
+	stop := pollGrowingFile(path, 10*time.Millisecond, func(n int64) {
+		atomic.StoreInt64(&lastSize, n)
+	})
+
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(path, make([]byte, 100), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	stop()
+
+	if got := atomic.LoadInt64(&lastSize); got != 100 {
+		t.Fatalf("expected pollGrowingFile to observe the file grow to 100 bytes, last reported %d", got)
+	}
+}
+
+func TestRateEstimatorAnimatesFromPriorThroughput(t *testing.T) {
+	r := &rateEstimator{}
+	// No history yet: animate should be a no-op (nil-safe stop func, no bar mutation).
+	stop := r.animate(newProgressDisplay([]string{"a"}, []int64{1000}), 0, 1000, time.Now())
+	stop()
+
+	r.record(1000, 100*time.Millisecond) // 10,000 bytes/sec
+	if got := r.bytesPerSec(); got < 9000 || got > 11000 {
+		t.Fatalf("bytesPerSec = %.0f, want ~10000", got)
+	}
+
+	display := newProgressDisplay([]string{"b"}, []int64{5000})
+	start := time.Now()
+	stopAnimating := r.animate(display, 0, 5000, start)
+	time.Sleep(350 * time.Millisecond)
+	stopAnimating()
+
+	got := atomic.LoadInt64(&display.bars[0].done)
+	if got <= 0 {
+		t.Fatalf("expected animate to have advanced chunk 0's progress, got %d", got)
+	}
+	if got >= 5000 {
+		t.Fatalf("expected animate's projection to stay below total before completion, got %d", got)
+	}
+}